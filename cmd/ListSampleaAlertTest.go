@@ -1,63 +1,190 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
+	"os"
+	"strconv"
 	"time"
 
 	"github.com/sendgrid/mc-contacts/lib/listsample"
 	m "github.com/sendgrid/mc-contacts-platform-tools/lib/migration_file"
+	"github.com/sendgrid/mcauto/metrics"
+	"github.com/sendgrid/mclogger/lib/logger"
+)
+
+const (
+	defaultBatchSize = 1000
+	defaultLogLevel  = "info"
 )
 
 type client struct {
-	red  listsample.DAL
+	red     listsample.DAL
+	profile *profile
+	size    int
+
+	workers       int
+	opsPerSecond  int
+	limiter       *rateLimiter
+	metricsLogger metrics.MetricLogger
+	summary       *runSummary
+
+	// until loadDir stops taking files, zero for no limit
+	until time.Time
+	// fileTimeout the time a loader holds the lease of a file, zero for no limit
+	fileTimeout time.Duration
 }
 
 func main() {
-	c := new()
+	configPath := flag.String("config", defaultConfigPath, "config file defining the profiles")
+	profileName := flag.String("profile", envOr("LISTSAMPLE_PROFILE", localProfileName), "target environment, or $LISTSAMPLE_PROFILE")
+	prodAcknowledged := flag.Bool("i-know-this-is-prod", false, "run write path commands against a production profile")
+	host := flag.String("host", os.Getenv("LISTSAMPLE_HOST"), "redis bootstrap host overriding the profile's, confirmed and rate limited as production, or $LISTSAMPLE_HOST")
+	dir := flag.String("dir", envOr("LISTSAMPLE_DIR", m.DirSnow), "directory of the snowflake contact files to load, or $LISTSAMPLE_DIR")
+	batchSize := flag.Int("batch-size", envIntOr("LISTSAMPLE_BATCH_SIZE", defaultBatchSize), "mutations per put, or $LISTSAMPLE_BATCH_SIZE")
+	maxSetSize := flag.Int("max-set-size", envIntOr("LISTSAMPLE_MAX_SET_SIZE", 0), "contacts kept per list sample, 0 for the DAL default, or $LISTSAMPLE_MAX_SET_SIZE")
+	logLevel := flag.String("log-level", envOr("LISTSAMPLE_LOG_LEVEL", defaultLogLevel), "log level, or $LISTSAMPLE_LOG_LEVEL")
+	workers := flag.Int("workers", envIntOr("LISTSAMPLE_WORKERS", 1), "files loaded concurrently, or $LISTSAMPLE_WORKERS")
+	opsPerSecond := flag.Int("ops-per-second", envIntOr("LISTSAMPLE_OPS_PER_SECOND", 0), "mutations per second across the workers, 0 for the profile's, or $LISTSAMPLE_OPS_PER_SECOND")
+	maxInFlight := flag.Int("max-in-flight", envIntOr("LISTSAMPLE_MAX_IN_FLIGHT", 0), "puts in flight across the workers, 0 for unlimited, or $LISTSAMPLE_MAX_IN_FLIGHT")
+	runID := flag.String("run-id", os.Getenv("LISTSAMPLE_RUN_ID"), "run id of the end of run summary, the profile and start time when empty, or $LISTSAMPLE_RUN_ID")
+	emfOutput := flag.String("emf", envOr("LISTSAMPLE_EMF", "-"), "file to append the EMF end of run summary to, - for stdout or empty to only log it, or $LISTSAMPLE_EMF")
+	fileTimeout := flag.Duration("file-timeout", envDurationOr("LISTSAMPLE_FILE_TIMEOUT", 0), "time a file is loaded within before it is reassigned, 0 for no limit, or $LISTSAMPLE_FILE_TIMEOUT")
+	notifyURL := flag.String("notify-url", os.Getenv("LISTSAMPLE_NOTIFY_URL"), "webhook to post to when a load, verify or drill ends, e.g. a Slack incoming webhook, or $LISTSAMPLE_NOTIFY_URL")
+	notifyOn := flag.String("notify-on", envOr("LISTSAMPLE_NOTIFY_ON", notifyAlways), "when to notify, always or failure, or $LISTSAMPLE_NOTIFY_ON")
+	notifyTemplate := flag.String("notify-template", os.Getenv("LISTSAMPLE_NOTIFY_TEMPLATE"), "text/template file of the notification payload, a Slack message when empty, or $LISTSAMPLE_NOTIFY_TEMPLATE")
+	flag.Parse()
+
+	logger.Setup(*logLevel, logger.DefaultFields{AppName: "listsample-loader"})
+
+	notify, err := newNotifier(*notifyURL, *notifyOn, *notifyTemplate)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
 
-	// put contacts into redis
-	if err := c.putRedis(m.DirSnow); err != nil {
-		fmt.Println("unable to put data into redis")
+	p, err := loadProfile(*configPath, *profileName)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	p.overrideHost(*host)
+	if *maxSetSize > 0 {
+		p.MaxSetSize = *maxSetSize
+	}
+	fmt.Printf("using profile %s (%s)\n", p.Name, p.Host)
+
+	args := flag.Args()
+	if len(args) > 1 && args[0] == "drill" && args[1] == "failover" {
+		if err := confirmProduction(p, "drill failover", *prodAcknowledged); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		summary := newRunSummary(defaultRunID(p))
+		if *runID != "" {
+			summary = newRunSummary(*runID)
+		}
+
+		err := drillFailover(p, args[2:])
+		notify.send(p, "drill failover", summary, err)
+		if err != nil {
+			fmt.Println("drill failed:", err)
+			os.Exit(1)
+		}
 		return
 	}
-}
 
-type config struct {
-	RedisCredentials string `default:"localhost:6379"`
-}
+	// preflight only reads the settings of the cluster
+	if len(args) > 0 && args[0] == "preflight" {
+		if err := preflight(p, args[1:]); err != nil {
+			fmt.Println("preflight failed:", err)
+			os.Exit(1)
+		}
+		return
+	}
 
-var cfg config
+	if *batchSize <= 0 || *workers <= 0 {
+		fmt.Println("batch-size and workers must be positive")
+		os.Exit(1)
+	}
 
-func (c *client) putRedis(dir string) error {
+	// verify only reads redis, it needs no production confirmation
+	if len(args) > 0 && args[0] == "verify" {
+		c := new(p, *batchSize)
+		if *runID != "" {
+			c.summary = newRunSummary(*runID)
+		}
+
+		err := c.verify(*dir, args[1:])
+		c.red.Close(context.Background())
+		notify.send(p, "verify", c.summary, err)
+		if err != nil {
+			fmt.Println("verify failed:", err)
+			os.Exit(1)
+		}
+		return
+	}
 
-	// Redis Insertion
+	// throttle below the profile's limit, e.g. during business hours, never above it
+	rate := p.OpsPerSecond
+	if *opsPerSecond > 0 {
+		if p.OpsPerSecond > 0 && *opsPerSecond > p.OpsPerSecond {
+			fmt.Printf("ops-per-second %d exceeds the %d ops/s of profile %s\n", *opsPerSecond, p.OpsPerSecond, p.Name)
+			os.Exit(1)
+		}
+		rate = *opsPerSecond
+	}
+	if p.hostOverridden && rate <= 0 {
+		fmt.Printf("--host overrides the host of profile %s, set --ops-per-second to limit the writes to it\n", p.Name)
+		os.Exit(1)
+	}
 
-	// build batch put
-	builder := listsample.NewListDeltaBatchBuilder()
+	command := "load"
+	if len(args) > 0 && args[0] == "schedule" {
+		command = "schedule"
+	}
 
-	for i := 0; i < 1000000; i++ {
+	if err := confirmProduction(p, command, *prodAcknowledged); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
 
-		builder.AddUpdate("Arthur Dent", "no list id", "no contact id", time.Now())
+	c := new(p, *batchSize)
+	c.workers = *workers
+	c.fileTimeout = *fileTimeout
+	c.opsPerSecond = rate
+	c.limiter = newRateLimiter(rate, *maxInFlight)
+	if *runID != "" {
+		c.summary = newRunSummary(*runID)
 	}
 
-	// run Batch put
-	err := c.red.Put(builder.Build())
+	// put contacts into redis, of every tenant of the schedule or of the dir
+	if command == "schedule" {
+		err = c.schedule(args[1:])
+	} else {
+		err = c.loadDir(*dir)
+	}
+	c.red.Close(context.Background())
+	if emitErr := c.summary.emit(p, command, *emfOutput); emitErr != nil {
+		fmt.Println("unable to emit the run summary:", emitErr)
+	}
+	notify.send(p, command, c.summary, err)
 	if err != nil {
-		fmt.Println("Error running batch put")
-		return err
+		fmt.Println("unable to put data into redis:", err)
+		os.Exit(1)
 	}
-	return nil
 }
 
 // new creates a new client for migration
-func new() *client {
+func new(p *profile, batchSize int) *client {
 
-	c := &client{}
+	c := &client{profile: p, size: batchSize, limiter: newRateLimiter(0, 0), metricsLogger: &metrics.StatsdMetrics{}}
+	c.summary = newRunSummary(defaultRunID(p))
 
 	// init redis
-	clusterOptions := listsample.NewClusterOptions()
-	clusterOptions.BoostrapHost = cfg.RedisCredentials
-	r, err := listsample.NewDAL(listsample.WithClusterOptions(clusterOptions))
+	r, err := p.newDAL(nil, 0)
 	if err != nil {
 		panic(err)
 	}
@@ -65,3 +192,33 @@ func new() *client {
 
 	return c
 }
+
+// envOr the value of the environment variable, def when it is not set
+func envOr(name, def string) string {
+
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+
+	return def
+}
+
+// envDurationOr the duration value of the environment variable, def when it is not set or not a duration
+func envDurationOr(name string, def time.Duration) time.Duration {
+
+	if v, err := time.ParseDuration(os.Getenv(name)); err == nil {
+		return v
+	}
+
+	return def
+}
+
+// envIntOr the integer value of the environment variable, def when it is not set or not an integer
+func envIntOr(name string, def int) int {
+
+	if v, err := strconv.Atoi(os.Getenv(name)); err == nil {
+		return v
+	}
+
+	return def
+}
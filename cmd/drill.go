@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/sendgrid/mc-contacts/lib/listsample"
+)
+
+const (
+	canaryUserID = "drill-canary"
+	canaryLists  = 1000
+	canaryTTL    = time.Hour
+)
+
+// drillWindow the canary operations observed during one report window
+type drillWindow struct {
+	start     time.Time
+	ops       int
+	errors    int
+	latencies []time.Duration
+}
+
+// drillFailover runs the failover drill: steady canary traffic through the DAL, a CLUSTER FAILOVER sent to a replica
+// part way through when -replica is set, and a report of the error and latency windows observed by the client.
+// Without -replica the failover is expected to be triggered out of band, e.g. by stopping a primary
+func drillFailover(p *profile, args []string) error {
+	defaultRate := 50
+	if p.OpsPerSecond > 0 && p.OpsPerSecond < defaultRate {
+		defaultRate = p.OpsPerSecond
+	}
+
+	flags := flag.NewFlagSet("drill failover", flag.ContinueOnError)
+	replica := flags.String("replica", "", "replica to send CLUSTER FAILOVER to, empty to only observe")
+	force := flags.Bool("force", false, "send CLUSTER FAILOVER FORCE, for when the primary is unreachable")
+	duration := flags.Duration("duration", time.Minute, "how long to run the canary traffic")
+	after := flags.Duration("after", 10*time.Second, "when to trigger the failover")
+	rate := flags.Int("rate", defaultRate, "canary operations per second, capped by the profile")
+	window := flags.Duration("window", time.Second, "report window")
+	retries := flags.Bool("retries", true, "run the DAL with the default retry policy")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *rate <= 0 || *window <= 0 {
+		return errors.New("rate and window must be positive")
+	}
+
+	if p.OpsPerSecond > 0 && *rate > p.OpsPerSecond {
+		return fmt.Errorf("rate %d exceeds the %d ops/s of profile %s", *rate, p.OpsPerSecond, p.Name)
+	}
+
+	var retryPolicy *listsample.RetryPolicy
+	if *retries {
+		retryPolicy = listsample.NewRetryPolicy()
+	}
+
+	// canaries expire so drills leave nothing behind
+	dal, err := p.newDAL(retryPolicy, canaryTTL)
+	if err != nil {
+		return err
+	}
+	defer dal.Close(context.Background())
+
+	var (
+		windows   []*drillWindow
+		current   = &drillWindow{start: time.Now()}
+		triggered time.Time
+		start     = time.Now()
+		lastError time.Time
+		recovered time.Time
+	)
+
+	ticker := time.NewTicker(time.Second / time.Duration(*rate))
+	defer ticker.Stop()
+
+	for n := 0; time.Since(start) < *duration; n++ {
+		<-ticker.C
+
+		// trigger the failover once
+		if *replica != "" && triggered.IsZero() && time.Since(start) >= *after {
+			triggered = time.Now()
+			if err := triggerFailover(p, *replica, *force); err != nil {
+				return err
+			}
+			fmt.Printf("%s failover sent to %s\n", triggered.Format(time.RFC3339), *replica)
+		}
+
+		if time.Since(current.start) >= *window {
+			windows = append(windows, current)
+			current = &drillWindow{start: time.Now()}
+		}
+
+		opStart := time.Now()
+		err := canaryOp(dal, n)
+		current.ops++
+		current.latencies = append(current.latencies, time.Since(opStart))
+
+		if err != nil {
+			current.errors++
+			lastError = time.Now()
+			recovered = time.Time{}
+		} else if !lastError.IsZero() && recovered.IsZero() {
+			recovered = time.Now()
+		}
+	}
+	windows = append(windows, current)
+
+	reportDrill(windows, triggered, lastError, recovered)
+	return nil
+}
+
+// canaryOp writes the nth canary contact and reads it back.  Canaries are spread over many lists so every node of the
+// cluster gets traffic, and a list is only reused once its last canary is a second old so the new one ranks first
+func canaryOp(dal listsample.DAL, n int) error {
+	contactID := "canary-" + strconv.Itoa(n)
+	canaryListID := "canary-" + strconv.Itoa(n%canaryLists)
+
+	builder := listsample.NewListDeltaBatchBuilder()
+	builder.AddUpdate(canaryUserID, canaryListID, contactID, time.Now())
+	if _, err := dal.Put(builder.Build()); err != nil {
+		return err
+	}
+
+	contacts, err := dal.Get(canaryUserID, canaryListID, 1)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range contacts {
+		if c == contactID {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("canary %s not read back", contactID)
+}
+
+// triggerFailover asks the replica to take over from its primary, dialed with the TLS and credentials of the profile
+func triggerFailover(p *profile, replica string, force bool) error {
+	conn, err := redis.Dial("tcp", replica, append(p.dialOptions(), redis.DialConnectTimeout(5*time.Second))...)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	args := []interface{}{"FAILOVER"}
+	if force {
+		args = append(args, "FORCE")
+	}
+
+	_, err = conn.Do("CLUSTER", args...)
+	return err
+}
+
+// reportDrill prints every window and a summary of the recovery
+func reportDrill(windows []*drillWindow, triggered, lastError, recovered time.Time) {
+	fmt.Printf("%-25s %6s %6s %10s %10s %10s\n", "window", "ops", "errors", "p50", "p99", "max")
+
+	var ops, errs int
+	var max time.Duration
+	for _, w := range windows {
+		sort.Slice(w.latencies, func(i, j int) bool { return w.latencies[i] < w.latencies[j] })
+
+		p50, p99, wmax := percentile(w.latencies, 0.5), percentile(w.latencies, 0.99), percentile(w.latencies, 1)
+		fmt.Printf("%-25s %6d %6d %10s %10s %10s\n", w.start.Format(time.RFC3339), w.ops, w.errors, p50, p99, wmax)
+
+		ops += w.ops
+		errs += w.errors
+		if wmax > max {
+			max = wmax
+		}
+	}
+
+	fmt.Printf("\n%d operations, %d errors, max latency %s\n", ops, errs, max)
+
+	if lastError.IsZero() {
+		fmt.Println("no errors observed")
+		return
+	}
+
+	if recovered.IsZero() {
+		fmt.Println("not recovered before the end of the drill")
+		return
+	}
+
+	if !triggered.IsZero() {
+		fmt.Printf("recovered %s after the failover was sent\n", recovered.Sub(triggered))
+	}
+	fmt.Printf("last error at %s, recovered at %s\n", lastError.Format(time.RFC3339Nano), recovered.Format(time.RFC3339Nano))
+}
+
+// percentile of sorted latencies
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	i := int(float64(len(sorted)-1) * p)
+	return sorted[i]
+}
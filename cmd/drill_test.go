@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sendgrid/mc-contacts/lib/listsample"
+)
+
+// forgetfulDAL a DAL whose writes are never read back, as a failed over primary that lost them
+type forgetfulDAL struct {
+	listsample.DAL
+}
+
+func (forgetfulDAL) Get(userID, listID string, maxSize int) ([]string, error) {
+	return []string{}, nil
+}
+
+func TestCanaryOp(t *testing.T) {
+
+	dal := listsample.NewInMemoryDAL()
+	for n := 0; n < 3; n++ {
+
+		if err := canaryOp(dal, n); err != nil {
+			t.Fatalf("canary %d: %v", n, err)
+		}
+	}
+
+	if err := canaryOp(forgetfulDAL{DAL: listsample.NewInMemoryDAL()}, 0); err == nil {
+		t.Errorf("canary not read back succeeded")
+	}
+}
+
+func TestPercentile(t *testing.T) {
+
+	sorted := make([]time.Duration, 100)
+	for i := range sorted {
+		sorted[i] = time.Duration(i+1) * time.Millisecond
+	}
+
+	tests := []struct {
+		p    float64
+		want time.Duration
+	}{
+		{p: 0, want: time.Millisecond},
+		{p: 0.5, want: 50 * time.Millisecond},
+		{p: 0.99, want: 99 * time.Millisecond},
+		{p: 1, want: 100 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		if got := percentile(sorted, tt.p); got != tt.want {
+			t.Errorf("percentile %v = %v, want %v", tt.p, got, tt.want)
+		}
+	}
+
+	if got := percentile(nil, 0.5); got != 0 {
+		t.Errorf("percentile of no latencies = %v, want 0", got)
+	}
+}
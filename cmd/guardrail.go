@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/user"
+	"strings"
+
+	"github.com/sendgrid/mclogger/lib/logger"
+)
+
+// isProduction true for profiles marked production, for profiles named like one in case the mark was forgotten, and
+// for profiles whose host was overridden as the host could be anything
+func (p *profile) isProduction() bool {
+	return p.Production || p.hostOverridden || strings.HasPrefix(strings.ToLower(p.Name), "prod")
+}
+
+// confirmProduction lets a write path command run against a production profile only once acknowledged, with
+// --i-know-this-is-prod or by typing the profile name on an interactive terminal, and writes an audit log entry of
+// who ran what.  Commands against other profiles always run
+func confirmProduction(p *profile, command string, acknowledged bool) error {
+	if !p.isProduction() {
+		return nil
+	}
+
+	how := "flag"
+	if !acknowledged {
+		if !interactive() {
+			return fmt.Errorf("%s writes to production profile %s, rerun with --i-know-this-is-prod", command, p.Name)
+		}
+
+		target := p.Host
+		if p.hostOverridden {
+			target += ", host overridden"
+		}
+
+		fmt.Printf("%s writes to PRODUCTION (profile %s, %s). Type the profile name to continue: ", command, p.Name, target)
+		typed, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if strings.TrimSpace(typed) != p.Name {
+			return fmt.Errorf("production confirmation for %s did not match, aborting", p.Name)
+		}
+		how = "typed"
+	}
+
+	hostname, _ := os.Hostname()
+	logger.NewEntry().
+		SetField("audit", true).
+		SetField("operator", operator()).
+		SetField("hostname", hostname).
+		SetField("profile", p.Name).
+		SetField("target", p.Host).
+		SetField("host_overridden", p.hostOverridden).
+		SetField("command", strings.Join(os.Args, " ")).
+		SetField("acknowledged", how).
+		Warn("Production write command acknowledged")
+
+	return nil
+}
+
+// interactive true when stdin is a terminal someone can type a confirmation on
+func interactive() bool {
+	stat, err := os.Stdin.Stat()
+	return err == nil && stat.Mode()&os.ModeCharDevice != 0
+}
+
+// operator the user running the command
+func operator() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+
+	return os.Getenv("USER")
+}
@@ -0,0 +1,36 @@
+package main
+
+import "testing"
+
+func TestConfirmProduction(t *testing.T) {
+
+	tests := []struct {
+		name         string
+		profile      profile
+		host         string
+		acknowledged bool
+		wantErr      bool
+	}{
+		{name: "local", profile: localProfile},
+		{name: "same host as the profile", profile: localProfile, host: localProfile.Host},
+		{name: "overridden host unconfirmed", profile: localProfile, host: "prod-redis:6379", wantErr: true},
+		{name: "overridden host acknowledged", profile: localProfile, host: "prod-redis:6379", acknowledged: true},
+		{name: "production profile unconfirmed", profile: profile{Name: "live", Host: "h:6379", Production: true}, wantErr: true},
+		{name: "named like production unconfirmed", profile: profile{Name: "prod-us", Host: "h:6379"}, wantErr: true},
+		{name: "production profile acknowledged", profile: profile{Name: "prod", Host: "h:6379"}, acknowledged: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			p := tt.profile
+			p.overrideHost(tt.host)
+
+			//tests don't run on a terminal, an unacknowledged production write is refused
+			err := confirmProduction(&p, "load", tt.acknowledged)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("confirmProduction = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
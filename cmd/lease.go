@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sendgrid/mclogger/lib/logger"
+)
+
+const (
+	loaderTimeoutsMetricName = "listsample.loader.timeouts"
+
+	// maxFileLeases the leases a file is given within a run before it is left for the next one
+	maxFileLeases = 3
+)
+
+// errLeaseReleased is returned by a loader whose file was reassigned after it exceeded its deadline
+var errLeaseReleased = errors.New("lease of the file was released")
+
+// fileLease the ownership of a file by the goroutine loading it.  Once released, the loader no longer touches the
+// checkpoint of the file nor the run's counts, and its context is cancelled so it stops putting, so the file can be
+// reassigned while the loader is still stuck
+type fileLease struct {
+	mu       sync.Mutex
+	released bool
+	cancel   context.CancelFunc
+}
+
+// newFileLease a lease held until released, and the context of its loader, cancelled once the lease is released
+func newFileLease() (*fileLease, context.Context) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &fileLease{cancel: cancel}, ctx
+}
+
+// do fn while the lease is held, errLeaseReleased when it was released.  A nil lease is always held
+func (l *fileLease) do(fn func() error) error {
+
+	if l == nil {
+		return fn()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.released {
+		return errLeaseReleased
+	}
+
+	return fn()
+}
+
+// release the lease, waiting for the checkpoint save in flight, if any, and cancel the context of its loader
+func (l *fileLease) release() {
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.released = true
+	l.cancel()
+}
+
+// loadFileWithin loads the file, releasing its lease and reassigning it to a new loader when the one holding it
+// exceeds the file timeout, e.g. stuck on a pathological file or a Redis node that stopped answering.  Every new lease
+// resumes from the checkpoint, so a large file progresses across leases.  The batch in flight when the lease is released
+// may be put again by the next lease, writing the same updates of the file again.  A file still not loaded after
+// maxFileLeases is failed, left for the next run.  The context of the stuck loader is cancelled, the
+// chunks of its put not yet sent fail and it stops before its next batch
+func (c *client) loadFileWithin(worker, fileName string) error {
+
+	if c.fileTimeout <= 0 {
+		return c.loadFile(context.Background(), worker, fileName, nil)
+	}
+
+	for leases := 1; ; leases++ {
+
+		lease, ctx := newFileLease()
+		done := make(chan error, 1)
+		go func() {
+			done <- c.loadFile(ctx, worker, fileName, lease)
+		}()
+
+		timer := time.NewTimer(c.fileTimeout)
+		select {
+		case err := <-done:
+			timer.Stop()
+			lease.release()
+			return err
+		case <-timer.C:
+		}
+
+		lease.release()
+		logger.NewEntry().
+			SetField("file", fileName).
+			SetField(loaderWorkerTag, worker).
+			SetField("lease", leases).
+			SetField("timeout", c.fileTimeout.String()).
+			Warn("Loader exceeded the file timeout, releasing its lease")
+		c.metricsLogger.PutCountWithTags(loaderTimeoutsMetricName, 1, map[string]string{loaderWorkerTag: worker})
+
+		if leases >= maxFileLeases {
+			return fmt.Errorf("file not loaded within %d leases of %s", leases, c.fileTimeout)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	m "github.com/sendgrid/mc-contacts-platform-tools/lib/migration_file"
+	"github.com/sendgrid/mc-contacts/lib/listsample"
+	"github.com/sendgrid/mcauto/metrics"
+)
+
+// stuckDAL a DAL whose first put hangs until its context is cancelled, as on a node that stopped answering
+type stuckDAL struct {
+	listsample.DAL
+
+	mu        sync.Mutex
+	puts      int
+	cancelled chan struct{}
+}
+
+func (d *stuckDAL) PutContext(ctx context.Context, batch *listsample.PutBatch) (*listsample.PutResult, error) {
+
+	d.mu.Lock()
+	d.puts++
+	first := d.puts == 1
+	d.mu.Unlock()
+
+	if first {
+		<-ctx.Done()
+		close(d.cancelled)
+		return nil, ctx.Err()
+	}
+
+	return &listsample.PutResult{}, nil
+}
+
+func TestLoadFileWithinCancelsReleasedLease(t *testing.T) {
+
+	fileName := filepath.Join(t.TempDir(), "snow_con_0.json")
+	w, err := m.NewWriter(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, contactID := range []string{"c1", "c2"} {
+
+		if err := w.Append(m.SnowContact{UserID: 1, ListID: "l", ContactID: contactID, UpdatedAt: 1600000000}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	dal := &stuckDAL{cancelled: make(chan struct{})}
+	c := &client{
+		red:           dal,
+		size:          1,
+		limiter:       newRateLimiter(0, 0),
+		metricsLogger: &metrics.StatsdMetrics{},
+		summary:       newRunSummary("test"),
+		fileTimeout:   100 * time.Millisecond,
+	}
+
+	if err := c.loadFileWithin("0", fileName); err != nil {
+		t.Fatalf("loadFileWithin: %v", err)
+	}
+
+	select {
+	case <-dal.cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("put of the released lease not cancelled")
+	}
+
+	// the stuck put, then both records again by the next lease
+	dal.mu.Lock()
+	defer dal.mu.Unlock()
+	if dal.puts != 3 {
+		t.Errorf("%d puts, want 3", dal.puts)
+	}
+	if !m.DoneProcessing(fileName) {
+		t.Errorf("file not done")
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	m "github.com/sendgrid/mc-contacts-platform-tools/lib/migration_file"
+	"github.com/sendgrid/mc-contacts/lib/listsample"
+	"github.com/sendgrid/mclogger/lib/logger"
+)
+
+// loader metrics, tagged with the worker
+const (
+	loaderRecordsMetricName = "listsample.loader.records"
+	loaderErrorsMetricName  = "listsample.loader.errors"
+	loaderFilesMetricName   = "listsample.loader.files"
+	loaderPutMetricName     = "listsample.loader.put"
+	loaderWorkerTag         = "worker"
+
+	loaderQuarantinedMetricName = "listsample.loader.quarantined"
+)
+
+// loadDir puts the snowflake contacts of every file of dir into redis, skipping the files already done.  The files are
+// spread over the workers, each owning the files it takes until they are loaded.  A file that fails is logged and left
+// for the next run, which resumes it from its checkpoint.  Corrupt files are quarantined instead and don't fail the run.
+// A file not loaded within the file timeout is reassigned, see loadFileWithin.  Once until passes no more files are
+// taken and errWindowClosed is returned, barring failures
+func (c *client) loadDir(dir string) error {
+
+	fileNames, err := m.Load(dir)
+	if err != nil {
+		return err
+	}
+
+	files := make(chan string)
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		failed int
+	)
+
+	workers := c.workers
+	if workers <= 0 {
+		workers = 1
+	}
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+
+		worker := strconv.Itoa(w)
+		go func() {
+			defer wg.Done()
+
+			for fileName := range files {
+				err := c.loadFileWithin(worker, fileName)
+				if m.IsCorrupt(err) && c.quarantine(worker, fileName, err) {
+					c.summary.quarantine()
+					continue
+				}
+				if err != nil {
+					logger.NewEntry().
+						SetField("file", fileName).
+						SetField(loaderWorkerTag, worker).
+						SetError(err).
+						Error("Unable to load file")
+					c.metricsLogger.PutCountWithTags(loaderErrorsMetricName, 1, map[string]string{loaderWorkerTag: worker})
+
+					mu.Lock()
+					failed++
+					mu.Unlock()
+					c.summary.fail()
+					continue
+				}
+
+				c.metricsLogger.PutCountWithTags(loaderFilesMetricName, 1, map[string]string{loaderWorkerTag: worker})
+				c.summary.file()
+			}
+		}()
+	}
+
+	closed := false
+	for _, fileName := range fileNames {
+
+		if m.DoneProcessing(fileName) {
+			continue
+		}
+		if !c.until.IsZero() && time.Now().After(c.until) {
+			closed = true
+			break
+		}
+		files <- fileName
+	}
+	close(files)
+	wg.Wait()
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d files failed to load", failed, len(fileNames))
+	}
+
+	if closed {
+		return errWindowClosed
+	}
+
+	return nil
+}
+
+// loadFile puts the contacts of the file in batches, starting after the records its checkpoint says were applied, and
+// marks it done once every record is.  The checkpoint only moves past batches fully applied, and only while the lease
+// is held.  Loading stops once ctx, cancelled when the lease is released, is done
+func (c *client) loadFile(ctx context.Context, worker, fileName string, lease *fileLease) error {
+
+	var (
+		r          *m.Reader
+		checkpoint *m.Checkpoint
+	)
+	err := lease.do(func() (err error) {
+		r, checkpoint, err = m.Resume(fileName)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	entry := logger.NewEntry().
+		SetField("file", fileName).
+		SetField(loaderWorkerTag, worker).
+		SetField("offset", checkpoint.Offset).
+		SetField("attempt", checkpoint.Attempts)
+	entry.Info("Loading file")
+
+	tags := map[string]string{loaderWorkerTag: worker}
+	for {
+
+		if ctx.Err() != nil {
+			return errLeaseReleased
+		}
+
+		contacts, err := readContacts(r, c.batchSize())
+		if err != nil {
+			return err
+		}
+		if len(contacts) == 0 {
+			break
+		}
+
+		// build batch put
+		builder := listsample.NewListDeltaBatchBuilder()
+		for _, contact := range contacts {
+			builder.AddUpdate(strconv.Itoa(contact.UserID), contact.ListID, contact.ContactID, time.Unix(contact.UpdatedAt, 0))
+		}
+
+		// run Batch put within the rate limits
+		c.limiter.acquire(len(contacts))
+		start := time.Now()
+		result, err := c.red.PutContext(ctx, builder.Build())
+		end := time.Now()
+		c.metricsLogger.PutTimingWithMetadata(loaderPutMetricName, tags, start, end)
+		c.limiter.release()
+
+		if err != nil || result.Failed().Len() > 0 {
+			return lease.do(func() error { return m.Commit(fileName, result, err) })
+		}
+
+		err = lease.do(func() error {
+			c.metricsLogger.PutCountWithTags(loaderRecordsMetricName, int64(len(contacts)), tags)
+			c.summary.put(len(contacts), end.Sub(start))
+
+			return checkpoint.Advance(int64(len(contacts)))
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return lease.do(func() error {
+		entry.SetField("records", checkpoint.Offset).Info("Loaded file")
+		return checkpoint.Done()
+	})
+}
+
+// quarantine moves the corrupt file out of the way of the next runs, returning false when it couldn't be moved
+func (c *client) quarantine(worker, fileName string, cause error) bool {
+
+	entry := logger.NewEntry().
+		SetField("file", fileName).
+		SetField(loaderWorkerTag, worker).
+		SetError(cause)
+
+	dest, err := m.Quarantine(fileName, cause)
+	if err != nil {
+		entry.SetField("quarantine_error", err.Error()).Error("Unable to quarantine corrupt file")
+		return false
+	}
+
+	entry.SetField("quarantined", dest).Error("Quarantined corrupt file")
+	c.metricsLogger.PutCountWithTags(loaderQuarantinedMetricName, 1, map[string]string{loaderWorkerTag: worker})
+	return true
+}
+
+// batchSize the mutations per put, capped by the ops per second so a batch fits in the rate limiter's bucket
+func (c *client) batchSize() int {
+
+	if c.opsPerSecond > 0 && c.opsPerSecond < c.size {
+		return c.opsPerSecond
+	}
+
+	return c.size
+}
+
+// readContacts the next n contacts of the file, fewer at its end
+func readContacts(r *m.Reader, n int) ([]m.SnowContact, error) {
+
+	contacts := make([]m.SnowContact, 0, n)
+	for len(contacts) < n {
+
+		var contact m.SnowContact
+		if err := r.Next(&contact); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		contacts = append(contacts, contact)
+	}
+
+	return contacts, nil
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	m "github.com/sendgrid/mc-contacts-platform-tools/lib/migration_file"
+)
+
+// TestMain removes the directories the migration_file init creates in the package directory, they are left alone
+// when not empty
+func TestMain(t *testing.M) {
+
+	code := t.Run()
+
+	for _, dir := range []string{m.DirUID, m.DirDyn, m.DirSnow} {
+		os.Remove(dir)
+	}
+
+	os.Exit(code)
+}
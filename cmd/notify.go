@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync/atomic"
+	"text/template"
+	"time"
+)
+
+// when notifications are sent
+const (
+	notifyAlways  = "always"
+	notifyFailure = "failure"
+)
+
+// defaultNotifyTemplate a Slack incoming webhook payload, also accepted by most chat webhooks
+const defaultNotifyTemplate = `{"text": {{printf "listsample %s %s on %s, run %s after %s: %d records, %d files, %d failed, %d quarantined%s" .Command .Status .Profile .RunID .Duration .Records .Files .FailedFiles .QuarantinedFiles .Cause | json}}}`
+
+// notification the fields of a notification template
+type notification struct {
+	Command          string
+	Profile          string
+	RunID            string
+	Status           string
+	Failed           bool
+	Error            string
+	Duration         time.Duration
+	Records          int64
+	Files            int64
+	FailedFiles      int64
+	QuarantinedFiles int64
+}
+
+// Cause the error of a failed run, prefixed to follow the counts of the default template
+func (n notification) Cause() string {
+
+	if n.Error == "" {
+		return ""
+	}
+
+	return ", " + n.Error
+}
+
+// notifier posts a notification to a webhook when a command finishes, so failed overnight runs reach on-call
+type notifier struct {
+	url      string
+	on       string
+	template *template.Template
+	client   *http.Client
+}
+
+// newNotifier creates a notifier posting to url when a run ends, or only when it fails with on set to notifyFailure.
+// templatePath is a text/template file rendering the payload from a notification, the default Slack payload when
+// empty.  Templates can escape strings with json.  No notification is sent without a url
+func newNotifier(url, on, templatePath string) (*notifier, error) {
+
+	if on != notifyAlways && on != notifyFailure {
+		return nil, fmt.Errorf("notify-on must be %s or %s, not %q", notifyAlways, notifyFailure, on)
+	}
+
+	source := defaultNotifyTemplate
+	if templatePath != "" {
+		b, err := ioutil.ReadFile(templatePath)
+		if err != nil {
+			return nil, err
+		}
+		source = string(b)
+	}
+
+	t, err := template.New("notification").Funcs(template.FuncMap{"json": jsonString}).Parse(source)
+	if err != nil {
+		return nil, err
+	}
+
+	return &notifier{url: url, on: on, template: t, client: &http.Client{Timeout: 10 * time.Second}}, nil
+}
+
+// jsonString the value as a JSON literal, quoted and escaped for strings
+func jsonString(v interface{}) (string, error) {
+
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+// send the notification of the end of the run of the command, failed when err is set.  Failing to notify is printed
+// and doesn't change the outcome of the run
+func (n *notifier) send(p *profile, command string, s *runSummary, runErr error) {
+
+	if n == nil || n.url == "" || (n.on == notifyFailure && runErr == nil) {
+		return
+	}
+
+	msg := notification{
+		Command:          command,
+		Profile:          p.Name,
+		RunID:            s.runID,
+		Status:           "succeeded",
+		Duration:         time.Since(s.start).Round(time.Second),
+		Records:          atomic.LoadInt64(&s.records),
+		Files:            atomic.LoadInt64(&s.files),
+		FailedFiles:      atomic.LoadInt64(&s.failed),
+		QuarantinedFiles: atomic.LoadInt64(&s.quarantined),
+	}
+	if runErr != nil {
+		msg.Status, msg.Failed, msg.Error = "failed", true, runErr.Error()
+	}
+
+	var payload bytes.Buffer
+	if err := n.template.Execute(&payload, msg); err != nil {
+		fmt.Println("unable to render the notification:", err)
+		return
+	}
+
+	resp, err := n.client.Post(n.url, "application/json", &payload)
+	if err != nil {
+		fmt.Println("unable to send the notification:", err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		fmt.Printf("notification rejected with status %s\n", resp.Status)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// webhook records the payloads posted to it
+func webhook(t *testing.T) (*httptest.Server, <-chan string) {
+	t.Helper()
+
+	payloads := make(chan string, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		payloads <- string(b)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, payloads
+}
+
+func TestNotifierSend(t *testing.T) {
+
+	p := &profile{Name: "staging"}
+	s := newRunSummary("staging-1")
+	s.put(10, 0)
+
+	tests := []struct {
+		name     string
+		on       string
+		runErr   error
+		wantSent bool
+		wantText string
+	}{
+		{name: "success always notified", on: notifyAlways, wantSent: true, wantText: "listsample load succeeded on staging, run staging-1"},
+		{name: "failure always notified", on: notifyAlways, runErr: errors.New("boom"), wantSent: true, wantText: `failed on staging`},
+		{name: "success not notified on failure", on: notifyFailure},
+		{name: "failure notified on failure", on: notifyFailure, runErr: errors.New(`node "a" down`), wantSent: true, wantText: `, node "a" down`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			server, payloads := webhook(t)
+			n, err := newNotifier(server.URL, tt.on, "")
+			if err != nil {
+				t.Fatalf("newNotifier: %v", err)
+			}
+
+			n.send(p, "load", s, tt.runErr)
+
+			select {
+			case payload := <-payloads:
+				if !tt.wantSent {
+					t.Fatalf("notification %s sent, want none", payload)
+				}
+
+				//the default template renders a valid Slack payload, the error escaped
+				var slack struct{ Text string }
+				if err := json.Unmarshal([]byte(payload), &slack); err != nil {
+					t.Fatalf("payload %s is not JSON: %v", payload, err)
+				}
+				if !strings.Contains(slack.Text, tt.wantText) || !strings.Contains(slack.Text, "10 records") {
+					t.Errorf("text %q, want it to contain %q and the records", slack.Text, tt.wantText)
+				}
+			default:
+				if tt.wantSent {
+					t.Fatal("no notification sent")
+				}
+			}
+		})
+	}
+}
+
+func TestNotifierTemplate(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "notify.tmpl")
+	if err := ioutil.WriteFile(path, []byte(`{"status": {{json .Status}}, "failed": {{.Failed}}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	server, payloads := webhook(t)
+	n, err := newNotifier(server.URL, notifyAlways, path)
+	if err != nil {
+		t.Fatalf("newNotifier: %v", err)
+	}
+
+	n.send(&profile{Name: "staging"}, "verify", newRunSummary("run"), errors.New("drifted"))
+
+	if got, want := <-payloads, `{"status": "failed", "failed": true}`; got != want {
+		t.Errorf("payload %s, want %s", got, want)
+	}
+}
+
+func TestNewNotifierInvalid(t *testing.T) {
+
+	if _, err := newNotifier("", "sometimes", ""); err == nil {
+		t.Errorf("newNotifier of an unknown notify-on succeeded")
+	}
+	if _, err := newNotifier("", notifyAlways, filepath.Join(t.TempDir(), "missing.tmpl")); err == nil {
+		t.Errorf("newNotifier of a missing template succeeded")
+	}
+
+	//no url, nothing sent
+	n, err := newNotifier("", notifyAlways, "")
+	if err != nil {
+		t.Fatalf("newNotifier: %v", err)
+	}
+	n.send(&profile{}, "load", newRunSummary("run"), nil)
+}
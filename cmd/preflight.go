@@ -0,0 +1,231 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+const (
+	// contactIDSize the size of an encoded contact id, a uuid, held in the sorted sets
+	contactIDSize = 36
+	// defaultMaxSetSize the contacts the DAL keeps per list sample when the profile doesn't set it
+	defaultMaxSetSize = 100
+)
+
+// finding levels of the preflight checks
+const (
+	preflightOK   = "OK"
+	preflightWarn = "WARN"
+	preflightFail = "FAIL"
+)
+
+// preflightFinding the outcome of a check of a setting of a node
+type preflightFinding struct {
+	node    string
+	setting string
+	level   string
+	message string
+}
+
+// preflightFeatures the features of the DAL the cluster settings are checked against
+type preflightFeatures struct {
+	keyTTL        time.Duration
+	notifications bool
+	maxSetSize    int
+}
+
+// preflight checks the settings of every primary of the cluster the DAL depends on, printing what to change for the
+// ones conflicting with the features used.  Fails when any setting would break a feature, settings only costing
+// memory or availability are warnings
+func preflight(p *profile, args []string) error {
+	flags := flag.NewFlagSet("preflight", flag.ContinueOnError)
+	keyTTL := flags.Duration("key-ttl", 0, "TTL the DAL sets on the list samples, 0 when they never expire")
+	notifications := flags.Bool("keyspace-notifications", false, "whether keyspace notification listeners watch the list samples")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	maxSetSize := p.MaxSetSize
+	if maxSetSize <= 0 {
+		maxSetSize = defaultMaxSetSize
+	}
+	features := preflightFeatures{keyTTL: *keyTTL, notifications: *notifications, maxSetSize: maxSetSize}
+
+	nodes, err := primaries(p)
+	if err != nil {
+		return err
+	}
+
+	var findings []preflightFinding
+	for _, node := range nodes {
+		settings, err := configGet(p, node, "maxmemory-policy", "cluster-require-full-coverage", "notify-keyspace-events",
+			"zset-max-ziplist-entries", "zset-max-ziplist-value", "zset-max-listpack-entries", "zset-max-listpack-value")
+		if err != nil {
+			findings = append(findings, preflightFinding{node: node, setting: "CONFIG GET", level: preflightFail,
+				message: fmt.Sprintf("unable to read the settings, %v. CONFIG may be renamed or disabled, e.g. on ElastiCache check the parameter group instead", err)})
+			continue
+		}
+
+		findings = append(findings, checkSettings(node, settings, features)...)
+	}
+
+	failed := 0
+	for _, f := range findings {
+		fmt.Printf("%-4s %-22s %-30s %s\n", f.level, f.node, f.setting, f.message)
+		if f.level == preflightFail {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d preflight checks failed", failed)
+	}
+
+	return nil
+}
+
+// checkSettings the findings of the settings of a node
+func checkSettings(node string, settings map[string]string, features preflightFeatures) []preflightFinding {
+	findings := []preflightFinding{}
+	add := func(setting, level, message string, args ...interface{}) {
+		findings = append(findings, preflightFinding{node: node, setting: setting, level: level, message: fmt.Sprintf(message, args...)})
+	}
+
+	// eviction
+	policy := settings["maxmemory-policy"]
+	switch {
+	case strings.HasPrefix(policy, "allkeys-"):
+		add("maxmemory-policy", preflightFail, "%s evicts list samples silently when memory is full, reads then miss contacts. Set noeviction, or volatile-ttl along with -key-ttl", policy)
+	case strings.HasPrefix(policy, "volatile-") && features.keyTTL <= 0:
+		add("maxmemory-policy", preflightWarn, "%s only evicts keys with a TTL and the list samples have none, puts fail with OOM once memory is full. Set noeviction to make that explicit, or configure a key TTL", policy)
+	default:
+		add("maxmemory-policy", preflightOK, "%s", policy)
+	}
+
+	// coverage
+	if settings["cluster-require-full-coverage"] == "yes" {
+		add("cluster-require-full-coverage", preflightWarn, "yes stops every node serving when a slot is uncovered, e.g. a shard lost both its primary and replica. Set no so the DAL keeps serving the covered slots")
+	} else {
+		add("cluster-require-full-coverage", preflightOK, "%s", settings["cluster-require-full-coverage"])
+	}
+
+	// keyspace notifications
+	events := settings["notify-keyspace-events"]
+	if features.notifications {
+		if missing := missingEvents(events, features.keyTTL > 0); missing != "" {
+			add("notify-keyspace-events", preflightFail, "%q doesn't publish what the listeners watch, add %q", events, missing)
+		} else {
+			add("notify-keyspace-events", preflightOK, "%q", events)
+		}
+	} else if events != "" {
+		add("notify-keyspace-events", preflightWarn, "%q publishes an event per mutation and no listener is configured, set \"\" unless something else consumes them", events)
+	} else {
+		add("notify-keyspace-events", preflightOK, "disabled")
+	}
+
+	// compact encoding of the sorted sets, named listpack since redis 7
+	prefix := "zset-max-ziplist-"
+	if _, ok := settings["zset-max-listpack-entries"]; ok {
+		prefix = "zset-max-listpack-"
+	}
+
+	if entries, err := strconv.Atoi(settings[prefix+"entries"]); err == nil && entries < features.maxSetSize+1 {
+		add(prefix+"entries", preflightWarn, "%d is below the %d contacts a list sample holds while a put truncates it, the samples use the skiplist encoding and several times the memory. Set at least %d", entries, features.maxSetSize+1, features.maxSetSize+1)
+	} else {
+		add(prefix+"entries", preflightOK, "%s", settings[prefix+"entries"])
+	}
+
+	if value, err := strconv.Atoi(settings[prefix+"value"]); err == nil && value < contactIDSize {
+		add(prefix+"value", preflightWarn, "%d bytes is below the %d bytes of a contact id, the samples use the skiplist encoding. Set at least %d", value, contactIDSize, contactIDSize)
+	} else {
+		add(prefix+"value", preflightOK, "%s", settings[prefix+"value"])
+	}
+
+	return findings
+}
+
+// missingEvents the notify-keyspace-events flags the listeners need that events lacks: keyspace or keyevent events
+// of sorted set commands, and of expirations when the samples have a TTL
+func missingEvents(events string, expiring bool) string {
+	var missing string
+	if !strings.ContainsAny(events, "KE") {
+		missing += "K"
+	}
+
+	all := strings.Contains(events, "A")
+	if !all && !strings.Contains(events, "z") {
+		missing += "z"
+	}
+	if expiring && !all && !strings.Contains(events, "x") {
+		missing += "x"
+	}
+
+	return missing
+}
+
+// primaries the primaries of the cluster of the profile, from CLUSTER NODES.  A node with cluster support disabled is
+// its own only primary
+func primaries(p *profile) ([]string, error) {
+	conn, err := redis.Dial("tcp", p.Host, append(p.dialOptions(), redis.DialConnectTimeout(5*time.Second))...)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	reply, err := redis.String(conn.Do("CLUSTER", "NODES"))
+	if err != nil {
+		if strings.Contains(err.Error(), "cluster support disabled") {
+			return []string{p.Host}, nil
+		}
+		return nil, err
+	}
+
+	var nodes []string
+	for _, line := range strings.Split(reply, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 || !strings.Contains(fields[2], "master") || strings.Contains(fields[2], "fail") {
+			continue
+		}
+
+		// ip:port@cport, ip:port@cport,hostname since redis 7
+		addr := strings.SplitN(fields[1], "@", 2)[0]
+		nodes = append(nodes, addr)
+	}
+
+	if len(nodes) == 0 {
+		return nil, errors.New("CLUSTER NODES lists no healthy primary")
+	}
+	sort.Strings(nodes)
+
+	return nodes, nil
+}
+
+// configGet the values of the settings of the node, leaving out the settings it doesn't know
+func configGet(p *profile, node string, settings ...string) (map[string]string, error) {
+	conn, err := redis.Dial("tcp", node, append(p.dialOptions(), redis.DialConnectTimeout(5*time.Second))...)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	values := make(map[string]string, len(settings))
+	for _, setting := range settings {
+		reply, err := redis.StringMap(conn.Do("CONFIG", "GET", setting))
+		if err != nil {
+			return nil, err
+		}
+
+		for k, v := range reply {
+			values[k] = v
+		}
+	}
+
+	return values, nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCheckSettings(t *testing.T) {
+	healthy := map[string]string{
+		"maxmemory-policy":              "noeviction",
+		"cluster-require-full-coverage": "no",
+		"notify-keyspace-events":        "",
+		"zset-max-listpack-entries":     "128",
+		"zset-max-listpack-value":       "64",
+	}
+	with := func(setting, value string) map[string]string {
+		settings := map[string]string{}
+		for k, v := range healthy {
+			settings[k] = v
+		}
+		settings[setting] = value
+		return settings
+	}
+
+	tests := []struct {
+		name     string
+		settings map[string]string
+		features preflightFeatures
+		setting  string
+		want     string
+	}{
+		{name: "noeviction", settings: healthy, setting: "maxmemory-policy", want: preflightOK},
+		{name: "allkeys eviction", settings: with("maxmemory-policy", "allkeys-lru"), setting: "maxmemory-policy", want: preflightFail},
+		{name: "volatile eviction without a ttl", settings: with("maxmemory-policy", "volatile-ttl"), setting: "maxmemory-policy", want: preflightWarn},
+		{name: "volatile eviction with a ttl", settings: with("maxmemory-policy", "volatile-ttl"), features: preflightFeatures{keyTTL: time.Hour}, setting: "maxmemory-policy", want: preflightOK},
+		{name: "full coverage required", settings: with("cluster-require-full-coverage", "yes"), setting: "cluster-require-full-coverage", want: preflightWarn},
+		{name: "events without listeners", settings: with("notify-keyspace-events", "Kz"), setting: "notify-keyspace-events", want: preflightWarn},
+		{name: "listeners without events", settings: healthy, features: preflightFeatures{notifications: true}, setting: "notify-keyspace-events", want: preflightFail},
+		{name: "listeners with events", settings: with("notify-keyspace-events", "Kz"), features: preflightFeatures{notifications: true}, setting: "notify-keyspace-events", want: preflightOK},
+		{name: "listpack too small for the set", settings: healthy, features: preflightFeatures{maxSetSize: 200}, setting: "zset-max-listpack-entries", want: preflightWarn},
+		{name: "listpack values too small for a contact id", settings: with("zset-max-listpack-value", "16"), setting: "zset-max-listpack-value", want: preflightWarn},
+		{
+			name:     "ziplist before redis 7",
+			settings: map[string]string{"zset-max-ziplist-entries": "64", "zset-max-ziplist-value": "64"},
+			features: preflightFeatures{maxSetSize: 100},
+			setting:  "zset-max-ziplist-entries",
+			want:     preflightWarn,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			for _, f := range checkSettings("n1:6379", tt.settings, tt.features) {
+				if f.setting == tt.setting {
+					got = f.level
+				}
+			}
+
+			if got != tt.want {
+				t.Errorf("%s %s, want %s", tt.setting, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMissingEvents(t *testing.T) {
+	tests := []struct {
+		events   string
+		expiring bool
+		want     string
+	}{
+		{events: "", want: "Kz"},
+		{events: "Kz", want: ""},
+		{events: "Ez", want: ""},
+		{events: "KA", expiring: true, want: ""},
+		{events: "Kz", expiring: true, want: "x"},
+		{events: "x", expiring: true, want: "Kz"},
+	}
+
+	for _, tt := range tests {
+		if got := missingEvents(tt.events, tt.expiring); got != tt.want {
+			t.Errorf("missingEvents(%q, %v) = %q, want %q", tt.events, tt.expiring, got, tt.want)
+		}
+	}
+}
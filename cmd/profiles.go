@@ -0,0 +1,146 @@
+package main
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/sendgrid/mc-contacts/lib/listsample"
+)
+
+const (
+	defaultConfigPath = "listsample.json"
+	localProfileName  = "local"
+)
+
+// config the config file, e.g.
+//
+//	{"profiles": {"staging": {"host": "staging-redis:6379", "tls": true, "password_env": "STAGING_REDIS_PASSWORD"}}}
+type config struct {
+	Profiles map[string]*profile `json:"profiles"`
+}
+
+// profile a target environment.  Every command runs against exactly one, selected with --profile, so no host is
+// ever hardcoded
+type profile struct {
+	Name string `json:"-"`
+
+	// Host the cluster bootstrap host
+	Host string `json:"host"`
+	// TLS connect over TLS
+	TLS bool `json:"tls"`
+	// TLSSkipVerify skip the verification of the server certificate, for self signed staging clusters only
+	TLSSkipVerify bool `json:"tls_skip_verify"`
+	// PasswordEnv the environment variable holding the password, so no credential is ever stored in the file
+	PasswordEnv string `json:"password_env"`
+
+	// Production write path commands require an explicit acknowledgement, see confirmProduction
+	Production bool `json:"production"`
+
+	// OpsPerSecond the max mutations per second commands send, 0 for unlimited
+	OpsPerSecond int `json:"ops_per_second"`
+	// MaxActiveConnections the max connections per node, 0 for the DAL default
+	MaxActiveConnections int `json:"max_active_connections"`
+	// MaxSetSize the contacts kept per list sample, 0 for the DAL default
+	MaxSetSize int `json:"max_set_size"`
+
+	// hostOverridden the host was replaced with --host, it is unverified so treated as production
+	hostOverridden bool
+}
+
+// localProfile used when the config file doesn't define the local profile
+var localProfile = profile{Name: localProfileName, Host: "localhost:6379"}
+
+// loadProfile the named profile of the config file at path
+func loadProfile(path, name string) (*profile, error) {
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) && name == localProfileName {
+		p := localProfile
+		return &p, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var c config
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %v", path, err)
+	}
+
+	p, ok := c.Profiles[name]
+	if !ok && name == localProfileName {
+		l := localProfile
+		p, ok = &l, true
+	}
+	if !ok {
+		names := make([]string, 0, len(c.Profiles))
+		for n := range c.Profiles {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown profile %q, %s defines %s", name, path, strings.Join(names, ", "))
+	}
+
+	p.Name = name
+	if p.Host == "" {
+		return nil, fmt.Errorf("profile %q has no host", name)
+	}
+
+	if p.PasswordEnv != "" && os.Getenv(p.PasswordEnv) == "" {
+		return nil, fmt.Errorf("profile %q expects the password in %s, which is not set", name, p.PasswordEnv)
+	}
+
+	return p, nil
+}
+
+// overrideHost points the profile at host.  Nothing says where a host other than the profile's is, so it is treated as
+// production: write path commands must be confirmed and rate limited
+func (p *profile) overrideHost(host string) {
+	if host == "" || host == p.Host {
+		return
+	}
+
+	p.Host = host
+	p.hostOverridden = true
+}
+
+// dialOptions the TLS and credentials of the profile
+func (p *profile) dialOptions() []redis.DialOption {
+	var options []redis.DialOption
+	if p.TLS {
+		options = append(options, redis.DialUseTLS(true), redis.DialTLSConfig(&tls.Config{InsecureSkipVerify: p.TLSSkipVerify}))
+	}
+
+	if p.PasswordEnv != "" {
+		options = append(options, redis.DialPassword(os.Getenv(p.PasswordEnv)))
+	}
+
+	return options
+}
+
+// newDAL a DAL connected to the profile's cluster
+func (p *profile) newDAL(retryPolicy *listsample.RetryPolicy, keyTTL time.Duration) (listsample.DAL, error) {
+	clusterOptions := listsample.NewClusterOptions()
+	clusterOptions.BoostrapHost = p.Host
+	if p.MaxActiveConnections > 0 {
+		clusterOptions.MaxActiveConnections = p.MaxActiveConnections
+	}
+
+	if retryPolicy == nil {
+		retryPolicy = &listsample.RetryPolicy{MaxAttempts: 1}
+	}
+
+	return listsample.NewDAL(
+		listsample.WithClusterOptions(clusterOptions),
+		listsample.WithDialOptions(p.dialOptions()...),
+		listsample.WithRetryPolicy(retryPolicy),
+		listsample.WithKeyTTL(keyTTL),
+		listsample.WithMaxSortedBuffer(p.MaxSetSize),
+	)
+}
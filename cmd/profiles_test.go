@@ -0,0 +1,91 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadProfile(t *testing.T) {
+
+	const file = `{"profiles": {
+		"staging": {"host": "staging-redis:6379", "tls": true, "password_env": "TEST_STAGING_REDIS_PASSWORD"},
+		"prod": {"host": "prod-redis:6379", "production": true},
+		"empty": {}
+	}}`
+	t.Setenv("TEST_STAGING_REDIS_PASSWORD", "secret")
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, defaultConfigPath)
+	if err := ioutil.WriteFile(path, []byte(file), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		profile  string
+		wantHost string
+		wantErr  string
+	}{
+		{name: "defined", path: path, profile: "staging", wantHost: "staging-redis:6379"},
+		{name: "local when the file doesn't define it", path: path, profile: localProfileName, wantHost: localProfile.Host},
+		{name: "local without a file", path: filepath.Join(dir, "missing.json"), profile: localProfileName, wantHost: localProfile.Host},
+		{name: "unknown lists the profiles", path: path, profile: "qa", wantErr: "defines empty, prod, staging"},
+		{name: "no host", path: path, profile: "empty", wantErr: "has no host"},
+		{name: "other profile without a file", path: filepath.Join(dir, "missing.json"), profile: "staging", wantErr: "no such file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			p, err := loadProfile(tt.path, tt.profile)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("loadProfile = %v, want an error with %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("loadProfile: %v", err)
+			}
+
+			if p.Name != tt.profile || p.Host != tt.wantHost {
+				t.Errorf("profile %s at %s, want %s at %s", p.Name, p.Host, tt.profile, tt.wantHost)
+			}
+		})
+	}
+}
+
+func TestLoadProfilePasswordNotSet(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), defaultConfigPath)
+	file := `{"profiles": {"staging": {"host": "h:6379", "password_env": "TEST_UNSET_REDIS_PASSWORD"}}}`
+	if err := ioutil.WriteFile(path, []byte(file), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := loadProfile(path, "staging"); err == nil || !strings.Contains(err.Error(), "TEST_UNSET_REDIS_PASSWORD") {
+		t.Errorf("loadProfile = %v, want an error naming the unset variable", err)
+	}
+}
+
+func TestProfileDialOptions(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		profile profile
+		want    int
+	}{
+		{name: "plain", profile: profile{Host: "h:6379"}},
+		{name: "tls", profile: profile{Host: "h:6379", TLS: true}, want: 2},
+		{name: "tls and password", profile: profile{Host: "h:6379", TLS: true, PasswordEnv: "TEST_REDIS_PASSWORD"}, want: 3},
+	}
+
+	for _, tt := range tests {
+		if got := len(tt.profile.dialOptions()); got != tt.want {
+			t.Errorf("%s: %d dial options, want %d", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// rateLimiter a token bucket of mutations per second shared by the workers, bursting up to a second of mutations,
+// along with a cap on the puts in flight.  Zero disables either limit
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+
+	inFlight chan struct{}
+
+	// parent the limiter of the run, shared by the limiters of its tenants
+	parent *rateLimiter
+}
+
+// newRateLimiter limits the workers to opsPerSecond mutations and maxInFlight concurrent puts
+func newRateLimiter(opsPerSecond, maxInFlight int) *rateLimiter {
+	l := &rateLimiter{rate: float64(opsPerSecond), tokens: float64(opsPerSecond), last: time.Now()}
+	if maxInFlight > 0 {
+		l.inFlight = make(chan struct{}, maxInFlight)
+	}
+
+	return l
+}
+
+// newTenantLimiter limits a tenant to opsPerSecond mutations, within the limits of the run's parent
+func newTenantLimiter(parent *rateLimiter, opsPerSecond int) *rateLimiter {
+	l := newRateLimiter(opsPerSecond, 0)
+	l.parent = parent
+
+	return l
+}
+
+// acquire waits until n mutations may be sent and a put slot is free, of the parent too.  A batch larger than the
+// bucket goes into debt, delaying the batches after it instead of never being sent.  Call release once the put returns
+func (l *rateLimiter) acquire(n int) {
+	wait := l.reserve(n)
+	if l.parent != nil {
+		if parentWait := l.parent.reserve(n); parentWait > wait {
+			wait = parentWait
+		}
+	}
+	time.Sleep(wait)
+
+	if l.inFlight != nil {
+		l.inFlight <- struct{}{}
+	}
+	if l.parent != nil && l.parent.inFlight != nil {
+		l.parent.inFlight <- struct{}{}
+	}
+}
+
+// release frees the put slot
+func (l *rateLimiter) release() {
+	if l.parent != nil && l.parent.inFlight != nil {
+		<-l.parent.inFlight
+	}
+	if l.inFlight != nil {
+		<-l.inFlight
+	}
+}
+
+// reserve takes n tokens, returning how long to wait until they are refilled
+func (l *rateLimiter) reserve(n int) time.Duration {
+	if l.rate <= 0 {
+		return 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.rate {
+		l.tokens = l.rate
+	}
+	l.last = now
+
+	l.tokens -= float64(n)
+	if l.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiterReserve(t *testing.T) {
+
+	tests := []struct {
+		name         string
+		opsPerSecond int
+		reserve      []int
+		wantLast     time.Duration
+	}{
+		{name: "unlimited", reserve: []int{1000, 1000}},
+		{name: "within the burst", opsPerSecond: 10, reserve: []int{4, 6}},
+		{name: "over the burst", opsPerSecond: 10, reserve: []int{10, 5}, wantLast: 500 * time.Millisecond},
+		{name: "a batch larger than the bucket goes into debt", opsPerSecond: 10, reserve: []int{30}, wantLast: 2 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			l := newRateLimiter(tt.opsPerSecond, 0)
+
+			var wait time.Duration
+			for _, n := range tt.reserve {
+				wait = l.reserve(n)
+			}
+
+			//the bucket refills while the test runs
+			if wait > tt.wantLast || wait < tt.wantLast-50*time.Millisecond {
+				t.Errorf("wait %v, want %v", wait, tt.wantLast)
+			}
+		})
+	}
+}
+
+func TestTenantLimiterSharesParent(t *testing.T) {
+
+	parent := newRateLimiter(10, 0)
+	a, b := newTenantLimiter(parent, 10), newTenantLimiter(parent, 10)
+
+	//the mutations of a take the tokens of the parent too
+	a.acquire(10)
+	a.release()
+
+	if wait := b.reserve(5); wait != 0 {
+		t.Errorf("tenant wait %v, want 0", wait)
+	}
+	if wait := parent.reserve(5); wait < 450*time.Millisecond {
+		t.Errorf("parent wait %v, want about 500ms", wait)
+	}
+}
+
+func TestRateLimiterInFlight(t *testing.T) {
+
+	parent := newRateLimiter(0, 1)
+	a, b := newTenantLimiter(parent, 0), newTenantLimiter(parent, 0)
+
+	a.acquire(1)
+
+	acquired := make(chan struct{})
+	go func() {
+
+		b.acquire(1)
+		close(acquired)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("acquired a put slot over the parent's max in flight")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	a.release()
+
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("put slot not freed by release")
+	}
+	b.release()
+}
@@ -0,0 +1,377 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"sync/atomic"
+	"text/template"
+	"time"
+
+	"github.com/sendgrid/mclogger/lib/logger"
+)
+
+// tenant statuses persisted across schedule runs
+const (
+	tenantPending = "pending"
+	tenantWaiting = "waiting"
+	tenantRunning = "running"
+	tenantDone    = "done"
+	tenantFailed  = "failed"
+	// tenantClosed the window closed before every file was loaded, the next run resumes it
+	tenantClosed = "closed"
+)
+
+// errWindowClosed is returned by loadDir when the window of the run closed before every file was dispatched
+var errWindowClosed = errors.New("window closed before every file was loaded")
+
+// schedulePlan the tenants file of the schedule command, e.g.
+//
+//	{"dir_template": "tenants/{{.Name}}/snow/", "tenants": [{"name": "acme", "ops_per_second": 500,
+//	"window": {"start": "22:00", "end": "06:00"}}]}
+type schedulePlan struct {
+	// DirTemplate a text/template of the directory of a tenant's files, for tenants without a dir
+	DirTemplate string    `json:"dir_template"`
+	Tenants     []*tenant `json:"tenants"`
+}
+
+// tenant an account whose files are backfilled on its own limits
+type tenant struct {
+	Name string `json:"name"`
+	// Dir the directory of the tenant's snowflake contact files
+	Dir string `json:"dir"`
+	// OpsPerSecond the max mutations per second of the tenant, capped by the run's.  0 for the run's
+	OpsPerSecond int `json:"ops_per_second"`
+	// Workers the files of the tenant loaded concurrently, 0 for the run's
+	Workers int `json:"workers"`
+	// Window the local time of day the tenant is loaded in, any time when empty
+	Window *tenantWindow `json:"window"`
+}
+
+// tenantWindow a time of day range, ending the next day when End is before Start
+type tenantWindow struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// tenantStatus the outcome of the last run of a tenant
+type tenantStatus struct {
+	Status           string    `json:"status"`
+	Started          time.Time `json:"started"`
+	Finished         time.Time `json:"finished"`
+	Records          int64     `json:"records"`
+	Files            int64     `json:"files"`
+	FailedFiles      int64     `json:"failed_files"`
+	QuarantinedFiles int64     `json:"quarantined_files"`
+	Error            string    `json:"error,omitempty"`
+}
+
+// scheduleStatus the status file of the schedule command, saved on every change so a rerun skips the tenants done
+type scheduleStatus struct {
+	path string
+
+	mu      sync.Mutex
+	Tenants map[string]*tenantStatus `json:"tenants"`
+}
+
+// schedule backfills the tenants of a tenants file one after the other, or -parallel at once, each within its own
+// rate limit and window and all within the run's.  A tenant waits for its window to open and stops taking files once
+// it closes, the next run resuming it from the checkpoints.  The status of every tenant is persisted to -status and
+// tenants done are skipped, unless -rerun
+func (c *client) schedule(args []string) error {
+
+	flags := flag.NewFlagSet("schedule", flag.ContinueOnError)
+	tenantsPath := flags.String("tenants", "tenants.json", "file of the tenants to backfill")
+	statusPath := flags.String("status", "schedule_status.json", "file the status of every tenant is persisted to")
+	parallel := flags.Int("parallel", 1, "tenants backfilled concurrently")
+	rerun := flags.Bool("rerun", false, "backfill the tenants already done again")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *parallel <= 0 {
+		return errors.New("parallel must be positive")
+	}
+
+	plan, err := loadSchedulePlan(*tenantsPath)
+	if err != nil {
+		return err
+	}
+
+	status, err := loadScheduleStatus(*statusPath)
+	if err != nil {
+		return err
+	}
+
+	var (
+		wg      sync.WaitGroup
+		failed  int32
+		running = make(chan struct{}, *parallel)
+	)
+
+	for _, t := range plan.Tenants {
+
+		if status.get(t.Name).Status == tenantDone && !*rerun {
+			fmt.Printf("tenant %s already done, skipping\n", t.Name)
+			continue
+		}
+		if err := status.set(t.Name, &tenantStatus{Status: tenantPending}); err != nil {
+			return err
+		}
+
+		// tenants start in the order of the file
+		running <- struct{}{}
+		wg.Add(1)
+		go func(t *tenant) {
+			defer wg.Done()
+			defer func() { <-running }()
+
+			if err := c.runTenant(t, status); err != nil {
+				atomic.AddInt32(&failed, 1)
+			}
+		}(t)
+	}
+	wg.Wait()
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d tenants failed, see %s", failed, len(plan.Tenants), *statusPath)
+	}
+
+	return nil
+}
+
+// runTenant backfills the files of the tenant with a client of its own limits, recording its status
+func (c *client) runTenant(t *tenant, status *scheduleStatus) error {
+
+	entry := logger.NewEntry().SetField("tenant", t.Name).SetField("dir", t.Dir)
+
+	var until time.Time
+	if t.Window != nil {
+		start, end, err := t.Window.span(time.Now())
+		if err != nil {
+			return c.tenantDone(t, status, &tenantStatus{}, err)
+		}
+
+		if wait := time.Until(start); wait > 0 {
+			status.set(t.Name, &tenantStatus{Status: tenantWaiting})
+			entry.SetField("opens", start).Info("Waiting for the tenant's window")
+			time.Sleep(wait)
+		}
+		until = end
+	}
+
+	// the run's rate caps the tenant's
+	rate := c.opsPerSecond
+	if t.OpsPerSecond > 0 && (rate <= 0 || t.OpsPerSecond < rate) {
+		rate = t.OpsPerSecond
+	}
+
+	workers := c.workers
+	if t.Workers > 0 {
+		workers = t.Workers
+	}
+
+	tc := &client{
+		red:           c.red,
+		profile:       c.profile,
+		size:          c.size,
+		workers:       workers,
+		opsPerSecond:  rate,
+		limiter:       newTenantLimiter(c.limiter, rate),
+		metricsLogger: c.metricsLogger,
+		summary:       newRunSummary(c.summary.runID + "-" + t.Name),
+		until:         until,
+		fileTimeout:   c.fileTimeout,
+	}
+
+	st := &tenantStatus{Status: tenantRunning, Started: time.Now()}
+	status.set(t.Name, st)
+	entry.SetField("ops_per_second", rate).Info("Backfilling tenant")
+
+	err := tc.loadDir(t.Dir)
+	c.summary.add(tc.summary)
+
+	st.Records = atomic.LoadInt64(&tc.summary.records)
+	st.Files = atomic.LoadInt64(&tc.summary.files)
+	st.FailedFiles = atomic.LoadInt64(&tc.summary.failed)
+	st.QuarantinedFiles = atomic.LoadInt64(&tc.summary.quarantined)
+
+	return c.tenantDone(t, status, st, err)
+}
+
+// tenantDone records the outcome of the tenant's run, a window closing being no failure
+func (c *client) tenantDone(t *tenant, status *scheduleStatus, st *tenantStatus, err error) error {
+
+	st.Finished = time.Now()
+	entry := logger.NewEntry().SetField("tenant", t.Name).SetField("records", st.Records)
+
+	switch {
+	case err == errWindowClosed:
+		st.Status, st.Error = tenantClosed, err.Error()
+		entry.Warn("Tenant's window closed, the next run resumes it")
+		err = nil
+	case err != nil:
+		st.Status, st.Error = tenantFailed, err.Error()
+		entry.SetError(err).Error("Unable to backfill tenant")
+	default:
+		st.Status = tenantDone
+		entry.Info("Backfilled tenant")
+	}
+
+	if saveErr := status.set(t.Name, st); saveErr != nil {
+		entry.SetError(saveErr).Error("Unable to save the schedule status")
+	}
+	fmt.Printf("tenant %s %s: %d records, %d files, %d failed\n", t.Name, st.Status, st.Records, st.Files, st.FailedFiles)
+
+	return err
+}
+
+// loadSchedulePlan the tenants of the file at path, their dir rendered from the template when they have none
+func loadSchedulePlan(path string) (*schedulePlan, error) {
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var plan schedulePlan
+	if err := json.Unmarshal(b, &plan); err != nil {
+		return nil, fmt.Errorf("invalid tenants file %s: %v", path, err)
+	}
+
+	var dirTemplate *template.Template
+	if plan.DirTemplate != "" {
+		if dirTemplate, err = template.New("dir").Parse(plan.DirTemplate); err != nil {
+			return nil, fmt.Errorf("invalid dir_template of %s: %v", path, err)
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, t := range plan.Tenants {
+
+		if t.Name == "" || seen[t.Name] {
+			return nil, fmt.Errorf("every tenant of %s needs a unique name, %q is not", path, t.Name)
+		}
+		seen[t.Name] = true
+
+		if t.Dir == "" {
+			if dirTemplate == nil {
+				return nil, fmt.Errorf("tenant %s has no dir and %s no dir_template", t.Name, path)
+			}
+
+			var dir bytes.Buffer
+			if err := dirTemplate.Execute(&dir, t); err != nil {
+				return nil, err
+			}
+			t.Dir = dir.String()
+		}
+
+		if t.Window != nil {
+			if _, _, err := t.Window.span(time.Now()); err != nil {
+				return nil, fmt.Errorf("invalid window of tenant %s: %v", t.Name, err)
+			}
+		}
+	}
+
+	return &plan, nil
+}
+
+// span the window open at now, or the next one to open
+func (w *tenantWindow) span(now time.Time) (time.Time, time.Time, error) {
+
+	start, err := clockOn(now, w.Start)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	end, err := clockOn(now, w.End)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	if !end.After(start) {
+		end = end.AddDate(0, 0, 1)
+	}
+
+	switch {
+	case now.Before(start) && now.Before(end.AddDate(0, 0, -1)):
+		// still in the window that opened yesterday
+		return start.AddDate(0, 0, -1), end.AddDate(0, 0, -1), nil
+	case !now.Before(end):
+		return start.AddDate(0, 0, 1), end.AddDate(0, 0, 1), nil
+	}
+
+	return start, end, nil
+}
+
+// clockOn the time of day, formatted 15:04, on the day of now
+func clockOn(now time.Time, clock string) (time.Time, error) {
+
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not a 15:04 time of day", clock)
+	}
+
+	return time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), 0, 0, now.Location()), nil
+}
+
+// loadScheduleStatus the status file at path, empty when it doesn't exist yet
+func loadScheduleStatus(path string) (*scheduleStatus, error) {
+
+	s := &scheduleStatus{path: path, Tenants: map[string]*tenantStatus{}}
+
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, fmt.Errorf("invalid status file %s: %v", path, err)
+	}
+	if s.Tenants == nil {
+		s.Tenants = map[string]*tenantStatus{}
+	}
+
+	return s, nil
+}
+
+// get the status of the tenant, pending when it never ran
+func (s *scheduleStatus) get(name string) tenantStatus {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if st, ok := s.Tenants[name]; ok {
+		return *st
+	}
+
+	return tenantStatus{Status: tenantPending}
+}
+
+// set the status of the tenant and save the file, replacing it atomically so a crash never leaves it truncated
+func (s *scheduleStatus) set(name string, st *tenantStatus) error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	copied := *st
+	s.Tenants[name] = &copied
+
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := s.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, s.path)
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTenantWindowSpan(t *testing.T) {
+
+	day := func(d, hour, minute int) time.Time {
+		return time.Date(2020, 1, d, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name      string
+		window    tenantWindow
+		now       time.Time
+		wantStart time.Time
+		wantEnd   time.Time
+	}{
+		{name: "before a day window", window: tenantWindow{"09:00", "17:00"}, now: day(2, 8, 0), wantStart: day(2, 9, 0), wantEnd: day(2, 17, 0)},
+		{name: "within a day window", window: tenantWindow{"09:00", "17:00"}, now: day(2, 12, 0), wantStart: day(2, 9, 0), wantEnd: day(2, 17, 0)},
+		{name: "after a day window", window: tenantWindow{"09:00", "17:00"}, now: day(2, 17, 0), wantStart: day(3, 9, 0), wantEnd: day(3, 17, 0)},
+		{name: "overnight window opened yesterday", window: tenantWindow{"22:00", "06:00"}, now: day(2, 3, 0), wantStart: day(1, 22, 0), wantEnd: day(2, 6, 0)},
+		{name: "between overnight windows", window: tenantWindow{"22:00", "06:00"}, now: day(2, 7, 0), wantStart: day(2, 22, 0), wantEnd: day(3, 6, 0)},
+		{name: "overnight window opened today", window: tenantWindow{"22:00", "06:00"}, now: day(2, 23, 0), wantStart: day(2, 22, 0), wantEnd: day(3, 6, 0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			start, end, err := tt.window.span(tt.now)
+			if err != nil {
+				t.Fatalf("span: %v", err)
+			}
+			if !start.Equal(tt.wantStart) || !end.Equal(tt.wantEnd) {
+				t.Errorf("span %v - %v, want %v - %v", start, end, tt.wantStart, tt.wantEnd)
+			}
+		})
+	}
+
+	if _, _, err := (&tenantWindow{Start: "9am", End: "17:00"}).span(day(2, 0, 0)); err == nil {
+		t.Errorf("span of an invalid time of day succeeded")
+	}
+}
+
+func TestLoadSchedulePlan(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		plan     string
+		wantDirs []string
+		wantErr  string
+	}{
+		{
+			name:     "dirs from the template",
+			plan:     `{"dir_template": "tenants/{{.Name}}/snow/", "tenants": [{"name": "acme"}, {"name": "globex", "dir": "custom/"}]}`,
+			wantDirs: []string{"tenants/acme/snow/", "custom/"},
+		},
+		{name: "duplicate name", plan: `{"tenants": [{"name": "acme", "dir": "a"}, {"name": "acme", "dir": "b"}]}`, wantErr: "unique name"},
+		{name: "no dir nor template", plan: `{"tenants": [{"name": "acme"}]}`, wantErr: "no dir_template"},
+		{name: "invalid window", plan: `{"tenants": [{"name": "acme", "dir": "a", "window": {"start": "25:00", "end": "06:00"}}]}`, wantErr: "invalid window"},
+		{name: "invalid json", plan: `{"tenants": [`, wantErr: "invalid tenants file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			path := filepath.Join(t.TempDir(), "tenants.json")
+			if err := ioutil.WriteFile(path, []byte(tt.plan), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			plan, err := loadSchedulePlan(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("loadSchedulePlan = %v, want an error with %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("loadSchedulePlan: %v", err)
+			}
+
+			for i, want := range tt.wantDirs {
+				if got := plan.Tenants[i].Dir; got != want {
+					t.Errorf("dir of %s %q, want %q", plan.Tenants[i].Name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestScheduleStatus(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "status.json")
+
+	s, err := loadScheduleStatus(path)
+	if err != nil {
+		t.Fatalf("loadScheduleStatus of a missing file: %v", err)
+	}
+	if got := s.get("acme").Status; got != tenantPending {
+		t.Errorf("status of a tenant that never ran %q, want %q", got, tenantPending)
+	}
+
+	if err := s.set("acme", &tenantStatus{Status: tenantDone, Records: 10}); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+
+	reloaded, err := loadScheduleStatus(path)
+	if err != nil {
+		t.Fatalf("loadScheduleStatus: %v", err)
+	}
+	if got := reloaded.get("acme"); got.Status != tenantDone || got.Records != 10 {
+		t.Errorf("reloaded status %+v, want done with 10 records", got)
+	}
+
+	if err := ioutil.WriteFile(path, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadScheduleStatus(path); err == nil {
+		t.Errorf("loadScheduleStatus of a truncated file succeeded")
+	}
+}
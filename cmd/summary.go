@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/sendgrid/mcauto/metrics"
+	"github.com/sendgrid/mclogger/lib/logger"
+)
+
+// summaryNamespace the CloudWatch namespace of the end of run summaries
+const summaryNamespace = "ListSample/Migration"
+
+// version of the tool, set at build time with -ldflags "-X main.version=<release>"
+var version = "dev"
+
+// runSummary the totals of a run, emitted once it ends so the throughput of runs can be charted across releases.  Safe
+// for concurrent use by the workers
+type runSummary struct {
+	runID string
+	start time.Time
+
+	records     int64
+	files       int64
+	failed      int64
+	quarantined int64
+	puts        int64
+	putNanos    int64
+}
+
+// newRunSummary starts the summary of the run, timing it from now
+func newRunSummary(runID string) *runSummary {
+	return &runSummary{runID: runID, start: time.Now()}
+}
+
+// defaultRunID identifies a run by its profile and start time
+func defaultRunID(p *profile) string {
+	return fmt.Sprintf("%s-%s", p.Name, time.Now().UTC().Format("20060102T150405Z"))
+}
+
+// put counts a put of n records that took d
+func (s *runSummary) put(n int, d time.Duration) {
+	atomic.AddInt64(&s.records, int64(n))
+	atomic.AddInt64(&s.puts, 1)
+	atomic.AddInt64(&s.putNanos, int64(d))
+}
+
+// file counts a file loaded
+func (s *runSummary) file() {
+	atomic.AddInt64(&s.files, 1)
+}
+
+// fail counts a file that failed to load
+func (s *runSummary) fail() {
+	atomic.AddInt64(&s.failed, 1)
+}
+
+// quarantine counts a corrupt file quarantined
+func (s *runSummary) quarantine() {
+	atomic.AddInt64(&s.quarantined, 1)
+}
+
+// add the totals of another summary, e.g. of a tenant of the run
+func (s *runSummary) add(o *runSummary) {
+	atomic.AddInt64(&s.records, atomic.LoadInt64(&o.records))
+	atomic.AddInt64(&s.files, atomic.LoadInt64(&o.files))
+	atomic.AddInt64(&s.failed, atomic.LoadInt64(&o.failed))
+	atomic.AddInt64(&s.quarantined, atomic.LoadInt64(&o.quarantined))
+	atomic.AddInt64(&s.puts, atomic.LoadInt64(&o.puts))
+	atomic.AddInt64(&s.putNanos, atomic.LoadInt64(&o.putNanos))
+}
+
+// document the summary as an EMF document of the run of the command against the profile, published per run and per
+// profile and release
+func (s *runSummary) document(p *profile, command string) *metrics.EMFDocument {
+
+	duration := time.Since(s.start)
+	records := atomic.LoadInt64(&s.records)
+	puts := atomic.LoadInt64(&s.puts)
+
+	d := metrics.NewEMFDocument(summaryNamespace)
+	d.Dimensions = [][]string{{"Profile", "RunID"}, {"Profile", "Version"}}
+	d.Properties["RunID"] = s.runID
+	d.Properties["Profile"] = p.Name
+	d.Properties["Version"] = version
+	d.Properties["Command"] = command
+
+	d.Put("Records", metrics.EMFUnitCount, float64(records))
+	d.Put("Files", metrics.EMFUnitCount, float64(atomic.LoadInt64(&s.files)))
+	d.Put("FailedFiles", metrics.EMFUnitCount, float64(atomic.LoadInt64(&s.failed)))
+	d.Put("QuarantinedFiles", metrics.EMFUnitCount, float64(atomic.LoadInt64(&s.quarantined)))
+	d.Put("Puts", metrics.EMFUnitCount, float64(puts))
+	d.Put("Duration", metrics.EMFUnitSeconds, duration.Seconds())
+
+	throughput := 0.0
+	if duration > 0 {
+		throughput = float64(records) / duration.Seconds()
+	}
+	d.Put("RecordsPerSecond", metrics.EMFUnitCountPerSecond, throughput)
+
+	latency := 0.0
+	if puts > 0 {
+		latency = float64(atomic.LoadInt64(&s.putNanos)) / float64(puts) / float64(time.Millisecond)
+	}
+	d.Put("PutLatency", metrics.EMFUnitMilliseconds, latency)
+
+	return d
+}
+
+// emit writes the summary to output, a file or - for stdout where the CloudWatch agent or the lambda runtime picks
+// it up.  An empty output only logs it
+func (s *runSummary) emit(p *profile, command, output string) error {
+
+	d := s.document(p, command)
+
+	entry := logger.NewEntry().SetField("run_id", s.runID)
+	for _, m := range d.Metrics {
+		entry.SetField(m.Name, m.Value)
+	}
+	entry.Info("Run summary")
+
+	if output == "" {
+		return nil
+	}
+
+	var w io.Writer = os.Stdout
+	if output != "-" {
+		f, err := os.OpenFile(output, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	_, err := d.WriteTo(w)
+	return err
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunSummaryDocument(t *testing.T) {
+
+	s := newRunSummary("staging-1")
+	s.start = time.Now().Add(-10 * time.Second)
+
+	s.put(100, 10*time.Millisecond)
+	s.file()
+	s.fail()
+
+	tenant := newRunSummary("staging-1")
+	tenant.put(50, 40*time.Millisecond)
+	tenant.file()
+	tenant.quarantine()
+	s.add(tenant)
+
+	d := s.document(&profile{Name: "staging"}, "load")
+
+	values := map[string]float64{}
+	for _, m := range d.Metrics {
+		values[m.Name] = m.Value
+	}
+
+	want := map[string]float64{"Records": 150, "Files": 2, "FailedFiles": 1, "QuarantinedFiles": 1, "Puts": 2, "PutLatency": 25}
+	for name, value := range want {
+		if values[name] != value {
+			t.Errorf("%s %v, want %v", name, values[name], value)
+		}
+	}
+	if rps := values["RecordsPerSecond"]; rps < 14 || rps > 15 {
+		t.Errorf("RecordsPerSecond %v, want about 15", rps)
+	}
+
+	for name, value := range map[string]string{"RunID": "staging-1", "Profile": "staging", "Version": version, "Command": "load"} {
+		if got := d.Properties[name]; got != value {
+			t.Errorf("property %s %q, want %q", name, got, value)
+		}
+	}
+}
+
+func TestRunSummaryEmit(t *testing.T) {
+
+	output := filepath.Join(t.TempDir(), "summary.json")
+	p := &profile{Name: "staging"}
+
+	//appended to, one document a run
+	for i := 0; i < 2; i++ {
+
+		if err := newRunSummary("run").emit(p, "verify", output); err != nil {
+			t.Fatalf("emit: %v", err)
+		}
+	}
+
+	b, err := ioutil.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("%d documents, want 2", len(lines))
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &doc); err != nil {
+		t.Fatalf("document is not JSON: %v", err)
+	}
+	if doc["Command"] != "verify" || doc["_aws"] == nil {
+		t.Errorf("document %v, want the verify command and the EMF metadata", doc)
+	}
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"io/ioutil"
+	"math"
+	"strconv"
+	"time"
+
+	m "github.com/sendgrid/mc-contacts-platform-tools/lib/migration_file"
+	"github.com/sendgrid/mc-contacts/lib/listsample"
+	"github.com/sendgrid/mclogger/lib/logger"
+)
+
+// verify metrics, summing up the drift report
+const (
+	verifyKeysMetricName       = "listsample.verify.keys"
+	verifyDriftedMetricName    = "listsample.verify.drifted"
+	verifyMissingMetricName    = "listsample.verify.missing"
+	verifyExtraMetricName      = "listsample.verify.extra"
+	verifyMisorderedMetricName = "listsample.verify.misordered"
+)
+
+// sampleBuckets the granularity of the sample rate, a hundredth of a percent
+const sampleBuckets = 10000
+
+// listKey a list sample of a user
+type listKey struct {
+	userID string
+	listID string
+}
+
+// driftReport the differences found between redis and the contents expected from the source files
+type driftReport struct {
+	Profile    string     `json:"profile"`
+	Dir        string     `json:"dir"`
+	SampleRate float64    `json:"sample_rate"`
+	Records    int        `json:"records"`
+	Keys       int        `json:"keys"`
+	Drifted    int        `json:"drifted"`
+	Missing    int        `json:"missing"`
+	Extra      int        `json:"extra"`
+	Misordered int        `json:"misordered"`
+	Drift      []keyDrift `json:"drift"`
+}
+
+// keyDrift the differences of one list sample.  Missing contacts are expected but not in redis, extra contacts are
+// in redis but not expected, and misordered contacts are in both at different ranks among the contacts in both
+type keyDrift struct {
+	UserID     string   `json:"user_id"`
+	ListID     string   `json:"list_id"`
+	Missing    []string `json:"missing,omitempty"`
+	Extra      []string `json:"extra,omitempty"`
+	Misordered []string `json:"misordered,omitempty"`
+}
+
+// verify replays the snowflake contact files of dir into an in memory DAL, which keeps the contacts redis is expected
+// to, i.e. the newest maxSetSize per list sample with deletes winning over updates of the same batch, then compares
+// every sampled list sample against redis and writes the drift found as a JSON report.  Returns an error when any
+// list sample drifted
+func (c *client) verify(dir string, args []string) error {
+
+	flags := flag.NewFlagSet("verify", flag.ContinueOnError)
+	sampleRate := flags.Float64("sample-rate", 100, "percentage of the list samples to check, picked by key so reruns check the same ones")
+	reportPath := flags.String("report", "drift_report.json", "file to write the JSON drift report to")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *sampleRate <= 0 || *sampleRate > 100 {
+		return errors.New("sample-rate must be greater than 0 and at most 100")
+	}
+
+	// step 1: replay the files of the sampled keys
+	expected := listsample.NewInMemoryDAL(listsample.WithMaxSortedBuffer(c.profile.MaxSetSize))
+	defer expected.Close(context.Background())
+
+	report := &driftReport{Profile: c.profile.Name, Dir: dir, SampleRate: *sampleRate, Drift: []keyDrift{}}
+	keys, err := c.replay(dir, expected, *sampleRate, report)
+	if err != nil {
+		return err
+	}
+
+	// step 2: compare each sampled key against redis
+	for _, key := range keys {
+
+		want, err := expected.GetWithScores(key.userID, key.listID, 0, math.MaxInt32)
+		if err != nil {
+			return err
+		}
+		got, err := c.red.GetWithScores(key.userID, key.listID, 0, math.MaxInt32)
+		if err != nil {
+			return fmt.Errorf("unable to get %s/%s: %v", key.userID, key.listID, err)
+		}
+
+		report.Keys++
+		drift := compare(key, contactIDs(want), contactIDs(got))
+		if len(drift.Missing)+len(drift.Extra)+len(drift.Misordered) == 0 {
+			continue
+		}
+
+		report.Drifted++
+		report.Missing += len(drift.Missing)
+		report.Extra += len(drift.Extra)
+		report.Misordered += len(drift.Misordered)
+		report.Drift = append(report.Drift, drift)
+	}
+
+	// step 3: report
+	c.metricsLogger.PutCount(verifyKeysMetricName, int64(report.Keys))
+	c.metricsLogger.PutCount(verifyDriftedMetricName, int64(report.Drifted))
+	c.metricsLogger.PutCount(verifyMissingMetricName, int64(report.Missing))
+	c.metricsLogger.PutCount(verifyExtraMetricName, int64(report.Extra))
+	c.metricsLogger.PutCount(verifyMisorderedMetricName, int64(report.Misordered))
+
+	b, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(*reportPath, b, 0644); err != nil {
+		return err
+	}
+
+	logger.NewEntry().
+		SetField("report", *reportPath).
+		SetField("keys", report.Keys).
+		SetField("drifted", report.Drifted).
+		SetField("missing", report.Missing).
+		SetField("extra", report.Extra).
+		SetField("misordered", report.Misordered).
+		Info("Verified list samples")
+	fmt.Printf("verified %d list samples from %d records: %d drifted (%d missing, %d extra, %d misordered), report in %s\n",
+		report.Keys, report.Records, report.Drifted, report.Missing, report.Extra, report.Misordered, *reportPath)
+
+	if report.Drifted > 0 {
+		return fmt.Errorf("%d of %d list samples drifted", report.Drifted, report.Keys)
+	}
+
+	return nil
+}
+
+// replay puts the contacts of the sampled keys of every file of dir into the DAL, in the batches the loader puts them
+// in so the samples are truncated the same way, and returns the keys sampled in the order first seen
+func (c *client) replay(dir string, dal listsample.DAL, sampleRate float64, report *driftReport) ([]listKey, error) {
+
+	fileNames, err := m.Load(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[listKey]bool{}
+	keys := []listKey{}
+	for _, fileName := range fileNames {
+
+		r, err := m.NewReader(fileName)
+		if err != nil {
+			return nil, err
+		}
+
+		for {
+			contacts, err := readContacts(r, c.batchSize())
+			if err != nil {
+				r.Close()
+				return nil, err
+			}
+			if len(contacts) == 0 {
+				break
+			}
+
+			builder := listsample.NewListDeltaBatchBuilder()
+			batched := 0
+			for _, contact := range contacts {
+
+				key := listKey{userID: strconv.Itoa(contact.UserID), listID: contact.ListID}
+				if !sampled(key, sampleRate) {
+					continue
+				}
+				if !seen[key] {
+					seen[key] = true
+					keys = append(keys, key)
+				}
+
+				batched++
+				builder.AddUpdate(key.userID, key.listID, contact.ContactID, time.Unix(contact.UpdatedAt, 0))
+			}
+
+			if batched == 0 {
+				continue
+			}
+			report.Records += batched
+
+			if _, err := dal.Put(builder.Build()); err != nil {
+				r.Close()
+				return nil, err
+			}
+		}
+		r.Close()
+	}
+
+	return keys, nil
+}
+
+// sampled true for the keys within the sample rate, a percentage.  The same keys are always sampled at a rate
+func sampled(key listKey, sampleRate float64) bool {
+
+	if sampleRate >= 100 {
+		return true
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(key.userID + ":" + key.listID))
+
+	return float64(h.Sum32()%sampleBuckets) < sampleRate*sampleBuckets/100
+}
+
+// compare the contacts expected in a list sample against the ones in redis, both newest first
+func compare(key listKey, want, got []string) keyDrift {
+
+	drift := keyDrift{UserID: key.userID, ListID: key.listID}
+
+	inGot := make(map[string]bool, len(got))
+	for _, contactID := range got {
+		inGot[contactID] = true
+	}
+	inWant := make(map[string]bool, len(want))
+	for _, contactID := range want {
+		inWant[contactID] = true
+	}
+
+	// the ranks among the contacts in both
+	var wantCommon, gotCommon []string
+	for _, contactID := range want {
+		if !inGot[contactID] {
+			drift.Missing = append(drift.Missing, contactID)
+			continue
+		}
+		wantCommon = append(wantCommon, contactID)
+	}
+	for _, contactID := range got {
+		if !inWant[contactID] {
+			drift.Extra = append(drift.Extra, contactID)
+			continue
+		}
+		gotCommon = append(gotCommon, contactID)
+	}
+
+	for i := range wantCommon {
+		if wantCommon[i] != gotCommon[i] {
+			drift.Misordered = append(drift.Misordered, wantCommon[i])
+		}
+	}
+
+	return drift
+}
+
+// contactIDs the contact ids of the entries, in order
+func contactIDs(entries []listsample.ListSampleEntry) []string {
+
+	ids := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		ids = append(ids, entry.ContactID)
+	}
+
+	return ids
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	m "github.com/sendgrid/mc-contacts-platform-tools/lib/migration_file"
+	"github.com/sendgrid/mc-contacts/lib/listsample"
+)
+
+func TestCompare(t *testing.T) {
+
+	key := listKey{userID: "1", listID: "l"}
+
+	tests := []struct {
+		name  string
+		want  []string
+		got   []string
+		drift keyDrift
+	}{
+		{name: "same", want: []string{"c1", "c2"}, got: []string{"c1", "c2"}, drift: keyDrift{}},
+		{name: "missing", want: []string{"c1", "c2"}, got: []string{"c1"}, drift: keyDrift{Missing: []string{"c2"}}},
+		{name: "extra", want: []string{"c1"}, got: []string{"c1", "c3"}, drift: keyDrift{Extra: []string{"c3"}}},
+		{
+			name:  "misordered among the contacts in both",
+			want:  []string{"c1", "c2", "c3"},
+			got:   []string{"c3", "c4", "c2"},
+			drift: keyDrift{Missing: []string{"c1"}, Extra: []string{"c4"}, Misordered: []string{"c2", "c3"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			tt.drift.UserID, tt.drift.ListID = key.userID, key.listID
+			if got := compare(key, tt.want, tt.got); !reflect.DeepEqual(got, tt.drift) {
+				t.Errorf("compare = %+v, want %+v", got, tt.drift)
+			}
+		})
+	}
+}
+
+func TestSampled(t *testing.T) {
+
+	keys := make([]listKey, 1000)
+	for i := range keys {
+		keys[i] = listKey{userID: string(rune('a' + i%26)), listID: string(rune('a' + i/26))}
+	}
+
+	count := func(rate float64) int {
+		n := 0
+		for _, key := range keys {
+			if sampled(key, rate) {
+				n++
+			}
+		}
+		return n
+	}
+
+	if got := count(100); got != len(keys) {
+		t.Errorf("%d keys sampled at 100%%, want every one", got)
+	}
+	if got := count(0); got != 0 {
+		t.Errorf("%d keys sampled at 0%%, want none", got)
+	}
+	if got := count(10); got < 50 || got > 150 {
+		t.Errorf("%d of 1000 keys sampled at 10%%, want about 100", got)
+	}
+
+	//the keys sampled at a rate are sampled at any higher rate
+	for _, key := range keys {
+		if sampled(key, 10) && !sampled(key, 20) {
+			t.Fatalf("%v sampled at 10%% but not at 20%%", key)
+		}
+	}
+}
+
+func TestReplay(t *testing.T) {
+
+	dir := t.TempDir() + string(filepath.Separator)
+	w, err := m.NewWriter(filepath.Join(dir, "snow_con_0.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, contact := range []m.SnowContact{
+		{UserID: 1, ListID: "l1", ContactID: "c1", UpdatedAt: 1600000000},
+		{UserID: 1, ListID: "l1", ContactID: "c2", UpdatedAt: 1600000001},
+		{UserID: 1, ListID: "l1", ContactID: "c3", UpdatedAt: 1600000002},
+		{UserID: 2, ListID: "l1", ContactID: "c1", UpdatedAt: 1600000000},
+	} {
+		if err := w.Append(contact); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	dal := listsample.NewInMemoryDAL(listsample.WithMaxSortedBuffer(2))
+	report := &driftReport{}
+	c := &client{size: 2}
+
+	keys, err := c.replay(dir, dal, 100, report)
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+
+	if want := []listKey{{"1", "l1"}, {"2", "l1"}}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("keys %v, want %v", keys, want)
+	}
+	if report.Records != 4 {
+		t.Errorf("%d records replayed, want 4", report.Records)
+	}
+
+	//truncated to the newest of the max set size, as redis keeps them
+	got, err := dal.Get("1", "l1", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"c3", "c2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected contacts %v, want %v", got, want)
+	}
+}